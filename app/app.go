@@ -6,19 +6,25 @@ import (
 
 	"github.com/theleeeo/thor/authorizer"
 	"github.com/theleeeo/thor/models"
+	"github.com/theleeeo/thor/refresh"
+	"github.com/theleeeo/thor/role"
 	"github.com/theleeeo/thor/sdk"
 	"github.com/theleeeo/thor/user"
 )
 
 type App struct {
-	auth  *authorizer.Authorizer
-	users *user.Service
+	auth    *authorizer.Authorizer
+	users   *user.Service
+	roles   *role.Service
+	refresh *refresh.Service
 }
 
-func New(authSrv *authorizer.Authorizer, userSrv *user.Service) *App {
+func New(authSrv *authorizer.Authorizer, userSrv *user.Service, roleSrv *role.Service, refreshSrv *refresh.Service) *App {
 	return &App{
-		auth:  authSrv,
-		users: userSrv,
+		auth:    authSrv,
+		users:   userSrv,
+		roles:   roleSrv,
+		refresh: refreshSrv,
 	}
 }
 
@@ -26,14 +32,93 @@ func (a *App) PublicKey() []byte {
 	return a.auth.PublicKey()
 }
 
+// CreateToken mints an access token for user, resolving their effective
+// permission set from the role package before embedding it in the JWT.
 func (a *App) CreateToken(ctx context.Context, user *models.User) (string, error) {
-	return a.auth.CreateToken(user)
+	perms, err := a.roles.EffectivePermissions(ctx, user.ID, user.Role)
+	if err != nil {
+		return "", err
+	}
+
+	return a.auth.CreateToken(user, perms)
 }
 
 func (a *App) DecodeToken(ctx context.Context, token string) (*authorizer.Claims, error) {
 	return a.auth.Decode(token)
 }
 
+// Login mints a fresh access token for user plus a refresh token that
+// starts a new rotation family tied to clientID/userAgent, so the session
+// later shows up in ListSessions and can be ended with Logout.
+func (a *App) Login(ctx context.Context, user *models.User, clientID, userAgent string) (accessToken, refreshToken string, err error) {
+	accessToken, err = a.CreateToken(ctx, user)
+	if err != nil {
+		return "", "", err
+	}
+
+	rt, err := a.refresh.Issue(ctx, user.ID, clientID, userAgent)
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, rt.Raw, nil
+}
+
+// Refresh rotates refreshToken for a new access/refresh token pair. The old
+// refresh token is revoked as part of the rotation; presenting it again is
+// treated as theft and revokes the whole session family.
+func (a *App) Refresh(ctx context.Context, refreshToken, userAgent string) (accessToken, newRefreshToken string, err error) {
+	rt, err := a.refresh.Rotate(ctx, refreshToken, userAgent)
+	if err != nil {
+		return "", "", err
+	}
+
+	u, err := a.users.GetByID(ctx, rt.UserID)
+	if err != nil {
+		return "", "", err
+	}
+
+	accessToken, err = a.CreateToken(ctx, &u.User)
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, rt.Raw, nil
+}
+
+// Logout revokes refreshToken's entire rotation family, ending that
+// session.
+func (a *App) Logout(ctx context.Context, refreshToken string) error {
+	return a.refresh.Revoke(ctx, refreshToken)
+}
+
+// ListSessions returns userID's active refresh-token sessions. Only the
+// user themselves may list their own sessions.
+func (a *App) ListSessions(ctx context.Context, userID string) ([]refresh.Token, error) {
+	claims := sdk.ClaimFromCtx(ctx)
+	if claims == nil {
+		return nil, errors.New("unauthorized")
+	}
+
+	if claims.UserID != userID {
+		return nil, errors.New("forbidden")
+	}
+
+	return a.refresh.ListSessions(ctx, userID)
+}
+
+// SignState returns an HMAC over data, keyed off the authorizer's private
+// key material, so the oauth package can bind a client-flow state value to
+// the session that issued it.
+func (a *App) SignState(data []byte) []byte {
+	return a.auth.SignState(data)
+}
+
+// VerifyState reports whether mac is a valid SignState signature over data.
+func (a *App) VerifyState(data, mac []byte) bool {
+	return a.auth.VerifyState(data, mac)
+}
+
 func (a *App) WhoAmI(ctx context.Context, token string) (*models.User, error) {
 	t, err := a.auth.Decode(token)
 	if err != nil {
@@ -72,7 +157,7 @@ func (a *App) GetUserByProviderID(ctx context.Context, providerID string) (*mode
 		return nil, errors.New("unauthorized")
 	}
 
-	if claims.Role != authorizer.RoleAdmin {
+	if !claims.Permissions.Has(role.PermAdmin) {
 		return nil, errors.New("forbidden")
 	}
 
@@ -0,0 +1,17 @@
+// Package auth provides first-class username+password login for Thor,
+// alongside the upstream-provider flow in the oauth package.
+package auth
+
+import (
+	"context"
+
+	"github.com/theleeeo/thor/models"
+)
+
+// LoginProvider authenticates an end user against credentials they present
+// directly to Thor. It mirrors the provider pattern oauth.OAuthHandler uses
+// for upstream providers, but for first-party credentials instead of a
+// redirect-based handshake.
+type LoginProvider interface {
+	AttemptLogin(ctx context.Context, username, password string) (models.User, error)
+}
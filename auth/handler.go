@@ -0,0 +1,263 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/theleeeo/thor/authorizer"
+	"github.com/theleeeo/thor/httperror"
+	"github.com/theleeeo/thor/lerror"
+	"github.com/theleeeo/thor/models"
+	"github.com/theleeeo/thor/refresh"
+	"github.com/theleeeo/thor/role"
+	"github.com/theleeeo/thor/sdk"
+	"github.com/theleeeo/thor/user"
+)
+
+// passwordClientID identifies the first-party password-login flow to the
+// refresh package, the same way oauth identifies its providers (e.g.
+// "oauth:google:default"), so sessions started through either flow show up
+// distinctly in ListSessions.
+const passwordClientID = "password"
+
+// Handler serves the first-party username+password login flow. It converges
+// on the same access+refresh cookie pair that oauth's serveCallback sets, so
+// password and OAuth logins are indistinguishable to anything downstream of
+// the cookies.
+type Handler struct {
+	auth       *authorizer.Authorizer
+	users      *user.Service
+	roles      *role.Service
+	refresh    *refresh.Service
+	provider   *PasswordProvider
+	appUrl     *url.URL
+	cookieName string
+}
+
+func NewHandler(auth *authorizer.Authorizer, users *user.Service, roles *role.Service, refreshSrv *refresh.Service, provider *PasswordProvider, appUrl *url.URL, cookieName string) *Handler {
+	return &Handler{
+		auth:       auth,
+		users:      users,
+		roles:      roles,
+		refresh:    refreshSrv,
+		provider:   provider,
+		appUrl:     appUrl,
+		cookieName: cookieName,
+	}
+}
+
+// createToken resolves u's effective permission set and mints an access
+// token embedding it.
+func (h *Handler) createToken(ctx context.Context, u *models.User) (string, error) {
+	perms, err := h.roles.EffectivePermissions(ctx, u.ID, u.Role)
+	if err != nil {
+		return "", err
+	}
+
+	return h.auth.CreateToken(u, perms)
+}
+
+// login mints an access token for u plus a refresh token that starts a new
+// rotation family, the same pair oauth's serveCallback issues via app.App.Login.
+func (h *Handler) login(ctx context.Context, u *models.User, userAgent string) (accessToken, refreshToken string, err error) {
+	accessToken, err = h.createToken(ctx, u)
+	if err != nil {
+		return "", "", err
+	}
+
+	rt, err := h.refresh.Issue(ctx, u.ID, passwordClientID, userAgent)
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, rt.Raw, nil
+}
+
+func (h *Handler) setCookie(w http.ResponseWriter, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     h.cookieName,
+		Value:    token,
+		Path:     "/",
+		SameSite: http.SameSiteLaxMode,
+		HttpOnly: true,
+		Secure:   !(h.appUrl.Scheme == "http"), // If the app url is http, then the cookie is not secure. Default to secure in all other cases.
+	})
+}
+
+// refreshCookieName matches oauth's convention so the refresh cookie this
+// handler sets is the same one oauth's /oauth/refresh and /oauth/logout
+// routes already read.
+func (h *Handler) refreshCookieName() string {
+	return h.cookieName + "_refresh"
+}
+
+func (h *Handler) setRefreshCookie(w http.ResponseWriter, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     h.refreshCookieName(),
+		Value:    token,
+		Path:     "/oauth",
+		SameSite: http.SameSiteLaxMode,
+		HttpOnly: true,
+		Secure:   !(h.appUrl.Scheme == "http"),
+	})
+}
+
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// ServeLogin handles POST /login.
+func (h *Handler) ServeLogin(w http.ResponseWriter, r *http.Request) {
+	if err := h.serveLogin(w, r); err != nil {
+		httperror.Write(w, err)
+	}
+}
+
+func (h *Handler) serveLogin(w http.ResponseWriter, r *http.Request) error {
+	var req loginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return lerror.Wrap(err, "failed to parse request body", http.StatusBadRequest)
+	}
+
+	u, err := h.provider.AttemptLogin(r.Context(), req.Username, req.Password)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			return lerror.Wrap(err, "invalid username or password", http.StatusUnauthorized)
+		}
+		return lerror.Wrap(err, "failed to authenticate", http.StatusInternalServerError)
+	}
+
+	token, refreshToken, err := h.login(r.Context(), &u, r.UserAgent())
+	if err != nil {
+		return lerror.Wrap(err, "failed to create token", http.StatusInternalServerError)
+	}
+
+	h.setCookie(w, token)
+	h.setRefreshCookie(w, refreshToken)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+type registerRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// ServeRegister handles POST /register.
+func (h *Handler) ServeRegister(w http.ResponseWriter, r *http.Request) {
+	if err := h.serveRegister(w, r); err != nil {
+		httperror.Write(w, err)
+	}
+}
+
+func (h *Handler) serveRegister(w http.ResponseWriter, r *http.Request) error {
+	var req registerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return lerror.Wrap(err, "failed to parse request body", http.StatusBadRequest)
+	}
+
+	u, err := h.provider.Register(r.Context(), &models.User{Email: req.Email}, req.Password)
+	if err != nil {
+		return lerror.Wrap(err, "failed to register user", http.StatusInternalServerError)
+	}
+
+	token, refreshToken, err := h.login(r.Context(), &u.User, r.UserAgent())
+	if err != nil {
+		return lerror.Wrap(err, "failed to create token", http.StatusInternalServerError)
+	}
+
+	h.setCookie(w, token)
+	h.setRefreshCookie(w, refreshToken)
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// ServeChangePassword handles POST /password/change for an already
+// authenticated user.
+func (h *Handler) ServeChangePassword(w http.ResponseWriter, r *http.Request) {
+	if err := h.serveChangePassword(w, r); err != nil {
+		httperror.Write(w, err)
+	}
+}
+
+func (h *Handler) serveChangePassword(w http.ResponseWriter, r *http.Request) error {
+	claims := sdk.ClaimFromCtx(r.Context())
+	if claims == nil {
+		return lerror.New("unauthorized", http.StatusUnauthorized)
+	}
+
+	var req changePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return lerror.Wrap(err, "failed to parse request body", http.StatusBadRequest)
+	}
+
+	current, err := h.users.GetByID(r.Context(), claims.UserID)
+	if err != nil {
+		return lerror.Wrap(err, "failed to load user", http.StatusInternalServerError)
+	}
+
+	if _, err := h.provider.AttemptLogin(r.Context(), current.Email, req.CurrentPassword); err != nil {
+		return lerror.Wrap(err, "current password is incorrect", http.StatusUnauthorized)
+	}
+
+	if err := h.provider.SetPassword(r.Context(), claims.UserID, req.NewPassword); err != nil {
+		return lerror.Wrap(err, "failed to set password", http.StatusInternalServerError)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+type requestPasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+// ServeRequestPasswordReset handles POST /password/reset. It always responds
+// 202 Accepted, whether or not the email matches an account, so the
+// endpoint can't be used to enumerate registered users.
+func (h *Handler) ServeRequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req requestPasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err == nil {
+		// RequestPasswordReset hands the token to the configured Mailer for
+		// delivery; errors here (including an unknown email) are deliberately
+		// not surfaced to the requester.
+		_, _ = h.provider.RequestPasswordReset(r.Context(), req.Email)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+type confirmPasswordResetRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// ServeConfirmPasswordReset handles POST /password/reset/confirm.
+func (h *Handler) ServeConfirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	if err := h.serveConfirmPasswordReset(w, r); err != nil {
+		httperror.Write(w, err)
+	}
+}
+
+func (h *Handler) serveConfirmPasswordReset(w http.ResponseWriter, r *http.Request) error {
+	var req confirmPasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return lerror.Wrap(err, "failed to parse request body", http.StatusBadRequest)
+	}
+
+	if err := h.provider.ConfirmPasswordReset(r.Context(), req.Token, req.NewPassword); err != nil {
+		return lerror.Wrap(err, "failed to reset password", http.StatusBadRequest)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+
+	"github.com/theleeeo/thor/models"
+	"github.com/theleeeo/thor/repo"
+	"github.com/theleeeo/thor/user"
+)
+
+const (
+	argonTime    = 1
+	argonMemory  = 64 * 1024
+	argonThreads = 4
+	argonKeyLen  = 32
+	saltLen      = 16
+
+	// passwordProviderType identifies a models.UserProvider created by this
+	// package, so constructUser-style linking in the oauth package can find
+	// it alongside upstream OAuth providers.
+	passwordProviderType = "password"
+
+	resetTokenTTL = time.Hour
+)
+
+// ErrInvalidCredentials is returned for any username/password mismatch. It
+// intentionally doesn't distinguish "unknown user" from "wrong password" so
+// callers can't use it to enumerate accounts.
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// Mailer delivers a password-reset token to a user out-of-band (e.g. email).
+// RequestPasswordReset calls it after the token has been persisted, so it is
+// actually reachable by the user that requested it rather than only ever
+// existing in the repo.
+type Mailer interface {
+	SendPasswordReset(ctx context.Context, email, token string) error
+}
+
+// PasswordProvider is a LoginProvider backed by argon2id password hashes
+// stored on the user record via repo.Repo.
+type PasswordProvider struct {
+	users  *user.Service
+	repo   repo.Repo
+	mailer Mailer
+}
+
+func NewPasswordProvider(users *user.Service, repo repo.Repo, mailer Mailer) *PasswordProvider {
+	return &PasswordProvider{users: users, repo: repo, mailer: mailer}
+}
+
+func generateCode() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	hash := argon2.IDKey([]byte(password), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+
+	return fmt.Sprintf("%s$%s",
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(hash),
+	), nil
+}
+
+func verifyPassword(password, encoded string) (bool, error) {
+	parts := strings.SplitN(encoded, "$", 2)
+	if len(parts) != 2 {
+		return false, fmt.Errorf("invalid password hash format")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false, fmt.Errorf("invalid password hash salt: %w", err)
+	}
+
+	want, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false, fmt.Errorf("invalid password hash: %w", err)
+	}
+
+	got := argon2.IDKey([]byte(password), salt, argonTime, argonMemory, argonThreads, uint32(len(want)))
+
+	return subtle.ConstantTimeCompare(got, want) == 1, nil
+}
+
+// AttemptLogin validates username (the user's email) and password against
+// the stored argon2id hash and returns the matching user on success.
+func (p *PasswordProvider) AttemptLogin(ctx context.Context, username, password string) (models.User, error) {
+	u, err := p.users.Get(ctx, repo.GetUserParams{Email: &username})
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			return models.User{}, ErrInvalidCredentials
+		}
+		return models.User{}, err
+	}
+
+	hash, err := p.repo.GetPasswordHash(ctx, u.ID)
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			return models.User{}, ErrInvalidCredentials
+		}
+		return models.User{}, err
+	}
+
+	ok, err := verifyPassword(password, hash)
+	if err != nil {
+		return models.User{}, err
+	}
+	if !ok {
+		return models.User{}, ErrInvalidCredentials
+	}
+
+	return u.User, nil
+}
+
+// SetPassword hashes and stores password as userID's credential.
+func (p *PasswordProvider) SetPassword(ctx context.Context, userID, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	return p.repo.SetPasswordHash(ctx, userID, hash)
+}
+
+// Register creates a new user with a password provider attached, so the
+// oauth package's constructUser can later find and link an upstream
+// provider to the same account by email.
+func (p *PasswordProvider) Register(ctx context.Context, userModel *models.User, password string) (user.User, error) {
+	u, err := p.users.CreateWithProvider(ctx, userModel, models.UserProvider{
+		Type:   passwordProviderType,
+		UserID: userModel.Email,
+	})
+	if err != nil {
+		return user.User{}, err
+	}
+
+	if err := p.SetPassword(ctx, u.ID, password); err != nil {
+		return user.User{}, err
+	}
+
+	return *u, nil
+}
+
+// RequestPasswordReset issues a reset token for the given email, valid for
+// resetTokenTTL, stores it so ConfirmPasswordReset can later redeem it, and
+// hands it to the mailer for delivery. It returns repo.ErrNotFound unchanged
+// so callers can decide whether to reveal that to the requester.
+func (p *PasswordProvider) RequestPasswordReset(ctx context.Context, email string) (string, error) {
+	u, err := p.users.Get(ctx, repo.GetUserParams{Email: &email})
+	if err != nil {
+		return "", err
+	}
+
+	token, err := generateCode()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate reset token: %w", err)
+	}
+
+	if err := p.repo.CreatePasswordResetToken(ctx, u.ID, token, time.Now().Add(resetTokenTTL)); err != nil {
+		return "", err
+	}
+
+	if err := p.mailer.SendPasswordReset(ctx, email, token); err != nil {
+		return "", fmt.Errorf("failed to send reset token: %w", err)
+	}
+
+	return token, nil
+}
+
+// ConfirmPasswordReset redeems a token minted by RequestPasswordReset and
+// sets newPassword as the account's credential. The token can only be used
+// once.
+func (p *PasswordProvider) ConfirmPasswordReset(ctx context.Context, token, newPassword string) error {
+	userID, err := p.repo.GetPasswordResetToken(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	if err := p.repo.DeletePasswordResetToken(ctx, token); err != nil {
+		return err
+	}
+
+	return p.SetPassword(ctx, userID, newPassword)
+}
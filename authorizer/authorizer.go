@@ -1,25 +1,32 @@
 package authorizer
 
 import (
-	"crypto"
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/theleeeo/thor/models"
+	"github.com/theleeeo/thor/role"
 )
 
+// Authorizer mints and verifies Thor's first-party JWTs. It holds a keyring
+// rather than a single keypair, so a key can be rotated without
+// invalidating the tokens it already signed: a retired key stays valid for
+// verification until its grace period elapses. See keyring.go.
 type Authorizer struct {
-	privateKey    crypto.PrivateKey
-	publicKey     crypto.PublicKey
-	rawPublicKey  []byte
+	keys          *keyring
 	validDuration time.Duration
 	appUrl        string
 
 	parser *jwt.Parser
 }
 
-func New(cfg *Config) (*Authorizer, error) {
+func New(cfg *Config, repo KeyRepo) (*Authorizer, error) {
 	pub, err := jwt.ParseEdPublicKeyFromPEM(cfg.PublicKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse public key: %w", err)
@@ -30,23 +37,46 @@ func New(cfg *Config) (*Authorizer, error) {
 		return nil, fmt.Errorf("failed to parse private key: %w", err)
 	}
 
+	kr, err := loadOrSeedKeyring(context.Background(), repo, &keyEntry{
+		kid:          keyID(cfg.PublicKey),
+		privateKey:   priv,
+		publicKey:    pub,
+		rawPublicKey: cfg.PublicKey,
+	}, cfg.KeyGracePeriod)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize keyring: %w", err)
+	}
+
 	return &Authorizer{
-		privateKey:    priv,
-		publicKey:     pub,
-		rawPublicKey:  cfg.PublicKey,
+		keys:          kr,
 		validDuration: cfg.ValidDuration,
 		appUrl:        cfg.AppUrl,
 		parser:        jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}), jwt.WithExpirationRequired()),
 	}, nil
 }
 
+// PublicKey returns the raw PEM-encoded public key of the active signing
+// key, for callers that only deal with a single key.
 func (a *Authorizer) PublicKey() []byte {
-	return a.rawPublicKey
+	return a.keys.active().rawPublicKey
+}
+
+// ValidDuration returns how long a freshly minted access or ID token
+// remains valid for, so callers that report it to clients (e.g. the OIDC
+// token endpoint's expires_in) stay in sync with however Thor is actually
+// configured instead of assuming a fixed value.
+func (a *Authorizer) ValidDuration() time.Duration {
+	return a.validDuration
 }
 
 func (a *Authorizer) Decode(token string) (*Claims, error) {
 	t, err := a.parser.ParseWithClaims(token, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return a.publicKey, nil
+		kid, _ := token.Header["kid"].(string)
+		key, ok := a.keys.lookup(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key.publicKey, nil
 	})
 
 	if err != nil {
@@ -61,20 +91,134 @@ func (a *Authorizer) Decode(token string) (*Claims, error) {
 	return claims, nil
 }
 
-func (a *Authorizer) CreateToken(user *models.User) (string, error) {
+// CreateToken mints an access token for user. perms is the effective
+// permission set resolved by the role package; it is embedded directly in
+// the JWT so that services using ClaimsExtractor can authorize requests
+// without a repo round-trip back to Thor. The token is signed by, and
+// tagged with the kid of, the keyring's current active key.
+func (a *Authorizer) CreateToken(user *models.User, perms role.Permission) (string, error) {
+	active := a.keys.active()
+
 	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA,
 		&Claims{
-			Issuer:    a.appUrl,
-			UserID:    user.ID,
-			ExpiresAt: time.Now().Add(a.validDuration),
-			Role:      user.Role,
+			Issuer:      a.appUrl,
+			UserID:      user.ID,
+			ExpiresAt:   time.Now().Add(a.validDuration),
+			Role:        user.Role,
+			Permissions: perms,
 		},
 	)
+	token.Header["kid"] = active.kid
 
-	tokenString, err := token.SignedString(a.privateKey)
+	tokenString, err := token.SignedString(active.privateKey)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
 
 	return tokenString, nil
 }
+
+// Rotate promotes a freshly generated Ed25519 keypair to be the active
+// signing key. The previously active key is demoted to verify-only for
+// gracePeriod, so tokens it already signed keep validating until they
+// expire naturally. A non-positive gracePeriod falls back to the grace
+// period the Authorizer was configured with (cfg.KeyGracePeriod, or
+// DefaultGracePeriod if that was unset).
+func (a *Authorizer) Rotate(ctx context.Context, newPrivPEM, newPubPEM []byte, gracePeriod time.Duration) error {
+	pub, err := jwt.ParseEdPublicKeyFromPEM(newPubPEM)
+	if err != nil {
+		return fmt.Errorf("failed to parse public key: %w", err)
+	}
+
+	priv, err := jwt.ParseEdPrivateKeyFromPEM(newPrivPEM)
+	if err != nil {
+		return fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	return a.keys.rotate(ctx, priv, pub, newPubPEM, gracePeriod)
+}
+
+// PublicKeys returns the JWKS of every key currently valid for
+// verification: the active signing key, plus any retired key still inside
+// its grace period.
+func (a *Authorizer) PublicKeys() []JWK {
+	return a.keys.jwks()
+}
+
+// stateHMACKey derives a key for signing OAuth client-flow state values from
+// the active key's private key, so the signature can only be produced by
+// the same Thor instance that holds it.
+func (a *Authorizer) stateHMACKey() []byte {
+	priv, ok := a.keys.active().privateKey.(ed25519.PrivateKey)
+	if !ok {
+		return nil
+	}
+
+	sum := sha256.Sum256(priv.Seed())
+	return sum[:]
+}
+
+// SignState returns an HMAC over data, keyed off material derived from this
+// authorizer's active private key. Used to bind an OAuth client-flow state
+// value to the session that issued it, so a leaked state can't be replayed
+// elsewhere.
+func (a *Authorizer) SignState(data []byte) []byte {
+	mac := hmac.New(sha256.New, a.stateHMACKey())
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// VerifyState reports whether mac is a valid SignState signature over data.
+func (a *Authorizer) VerifyState(data, mac []byte) bool {
+	return hmac.Equal(mac, a.SignState(data))
+}
+
+// Issuer returns the issuer identifier Thor presents as, both in first-party
+// JWTs and as the `issuer` of its OIDC discovery document.
+func (a *Authorizer) Issuer() string {
+	return a.appUrl
+}
+
+// keyID derives a stable kid from the raw PEM-encoded public key, so clients
+// can tell keys apart across rotations without us keeping a separate
+// counter.
+func keyID(rawPublicKey []byte) string {
+	sum := sha256.Sum256(rawPublicKey)
+	return hex.EncodeToString(sum[:8])
+}
+
+// IDClaims are the claims carried by an OIDC id_token.
+type IDClaims struct {
+	jwt.RegisteredClaims
+	Nonce string `json:"nonce,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+// CreateIDToken mints a short-lived id_token for user, bound to the client
+// that requested it (audience) and, when the client supplied one, the nonce
+// that ties it back to the original /authorize request.
+func (a *Authorizer) CreateIDToken(user *models.User, audience, nonce string) (string, error) {
+	active := a.keys.active()
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA,
+		&IDClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    a.appUrl,
+				Subject:   user.ID,
+				Audience:  jwt.ClaimStrings{audience},
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(a.validDuration)),
+			},
+			Nonce: nonce,
+			Email: user.Email,
+		},
+	)
+	token.Header["kid"] = active.kid
+
+	tokenString, err := token.SignedString(active.privateKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign id token: %w", err)
+	}
+
+	return tokenString, nil
+}
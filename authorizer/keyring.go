@@ -0,0 +1,283 @@
+package authorizer
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWK is the JSON Web Key representation of an Ed25519 public key, as
+// published on the /.well-known/jwks.json endpoint.
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// DefaultGracePeriod is how long a retired key remains valid for
+// verification after Rotate demotes it, giving tokens it already signed
+// time to expire naturally instead of being invalidated mid-flight.
+const DefaultGracePeriod = 24 * time.Hour
+
+// keyEntry is one key in the keyring. privateKey is nil once the key has
+// been retired: it can still verify tokens but can no longer sign new ones.
+type keyEntry struct {
+	kid          string
+	privateKey   crypto.PrivateKey
+	publicKey    crypto.PublicKey
+	rawPublicKey []byte
+	expiresAt    *time.Time
+}
+
+func (k *keyEntry) expired() bool {
+	return k.expiresAt != nil && time.Now().After(*k.expiresAt)
+}
+
+// StoredKey is how a keyEntry is persisted via repo.Repo, so a rotation
+// survives restarts and is consistent across replicas.
+type StoredKey struct {
+	Kid        string
+	PrivateKey []byte // PEM; empty once the key has been retired
+	PublicKey  []byte // PEM
+	Active     bool
+	ExpiresAt  time.Time // zero means "doesn't expire" (the active key)
+}
+
+// KeyRepo is the subset of repo.Repo the authorizer needs to persist its
+// keyring.
+type KeyRepo interface {
+	SaveKeyring(ctx context.Context, keys []StoredKey) error
+	LoadKeyring(ctx context.Context) ([]StoredKey, error)
+}
+
+// keyring holds every key the authorizer currently knows about: one active
+// signing key plus zero or more retired, verify-only keys.
+type keyring struct {
+	mu          sync.RWMutex
+	activeKid   string
+	keys        map[string]*keyEntry
+	gracePeriod time.Duration
+	repo        KeyRepo
+}
+
+// loadOrSeedKeyring restores the keyring from repo, or - on first boot, when
+// repo has nothing saved yet, or when repo is nil - seeds it with seed as
+// the sole active key. gracePeriod is the default grace period applied to a
+// rotation that doesn't specify its own; a non-positive value falls back to
+// DefaultGracePeriod.
+func loadOrSeedKeyring(ctx context.Context, repo KeyRepo, seed *keyEntry, gracePeriod time.Duration) (*keyring, error) {
+	if gracePeriod <= 0 {
+		gracePeriod = DefaultGracePeriod
+	}
+
+	kr := &keyring{
+		keys:        make(map[string]*keyEntry),
+		gracePeriod: gracePeriod,
+		repo:        repo,
+	}
+
+	if repo != nil {
+		stored, err := repo.LoadKeyring(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(stored) > 0 {
+			for _, sk := range stored {
+				entry, err := entryFromStored(sk)
+				if err != nil {
+					return nil, err
+				}
+				kr.keys[entry.kid] = entry
+				if sk.Active {
+					kr.activeKid = entry.kid
+				}
+			}
+			return kr, nil
+		}
+	}
+
+	kr.keys[seed.kid] = seed
+	kr.activeKid = seed.kid
+
+	if repo != nil {
+		if err := kr.persist(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return kr, nil
+}
+
+func entryFromStored(sk StoredKey) (*keyEntry, error) {
+	pub, err := jwt.ParseEdPublicKeyFromPEM(sk.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse stored public key %q: %w", sk.Kid, err)
+	}
+
+	entry := &keyEntry{
+		kid:          sk.Kid,
+		publicKey:    pub,
+		rawPublicKey: sk.PublicKey,
+	}
+
+	if len(sk.PrivateKey) > 0 {
+		priv, err := jwt.ParseEdPrivateKeyFromPEM(sk.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse stored private key %q: %w", sk.Kid, err)
+		}
+		entry.privateKey = priv
+	}
+
+	if !sk.ExpiresAt.IsZero() {
+		expiresAt := sk.ExpiresAt
+		entry.expiresAt = &expiresAt
+	}
+
+	return entry, nil
+}
+
+// activeKey is a race-free snapshot of the keyring's active signing key:
+// the fields a caller needs are copied out while the lock is held, rather
+// than handing back the keyEntry pointer itself. rotate mutates a demoted
+// keyEntry's fields in place, so returning the pointer would let a caller
+// read those same fields with no lock held, racing with rotate.
+type activeKey struct {
+	kid          string
+	privateKey   crypto.PrivateKey
+	rawPublicKey []byte
+}
+
+func (kr *keyring) active() activeKey {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	e := kr.keys[kr.activeKid]
+	return activeKey{kid: e.kid, privateKey: e.privateKey, rawPublicKey: e.rawPublicKey}
+}
+
+// lookup returns the key for kid, as long as it hasn't passed its grace
+// period expiry.
+func (kr *keyring) lookup(kid string) (*keyEntry, bool) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	k, ok := kr.keys[kid]
+	if !ok || k.expired() {
+		return nil, false
+	}
+	return k, true
+}
+
+func (kr *keyring) jwks() []JWK {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	var out []JWK
+	for _, k := range kr.keys {
+		if k.expired() {
+			continue
+		}
+		pub, ok := k.publicKey.(ed25519.PublicKey)
+		if !ok {
+			continue
+		}
+		out = append(out, JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(pub),
+			Use: "sig",
+			Alg: jwt.SigningMethodEdDSA.Alg(),
+			Kid: k.kid,
+		})
+	}
+	return out
+}
+
+// rotate promotes a freshly loaded key to active and demotes the current
+// active key to verify-only, valid for gracePeriod so tokens it already
+// signed keep validating until they expire naturally. A non-positive
+// gracePeriod falls back to the keyring's configured default.
+func (kr *keyring) rotate(ctx context.Context, newPriv crypto.PrivateKey, newPub crypto.PublicKey, rawPub []byte, gracePeriod time.Duration) error {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	if gracePeriod <= 0 {
+		gracePeriod = kr.gracePeriod
+	}
+
+	if old, ok := kr.keys[kr.activeKid]; ok {
+		expiresAt := time.Now().Add(gracePeriod)
+		old.privateKey = nil
+		old.expiresAt = &expiresAt
+	}
+
+	newKid := keyID(rawPub)
+	kr.keys[newKid] = &keyEntry{
+		kid:          newKid,
+		privateKey:   newPriv,
+		publicKey:    newPub,
+		rawPublicKey: rawPub,
+	}
+	kr.activeKid = newKid
+
+	if kr.repo == nil {
+		return nil
+	}
+	return kr.persistLocked(ctx)
+}
+
+func (kr *keyring) persist(ctx context.Context) error {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	return kr.persistLocked(ctx)
+}
+
+// persistLocked saves the keyring via repo. Callers must hold kr.mu.
+func (kr *keyring) persistLocked(ctx context.Context) error {
+	stored := make([]StoredKey, 0, len(kr.keys))
+	for kid, k := range kr.keys {
+		sk := StoredKey{
+			Kid:       kid,
+			PublicKey: k.rawPublicKey,
+			Active:    kid == kr.activeKid,
+		}
+		if k.expiresAt != nil {
+			sk.ExpiresAt = *k.expiresAt
+		}
+
+		// Only the active key's private half needs to survive a restart;
+		// a retired key is verify-only and has none to save.
+		if priv, ok := k.privateKey.(ed25519.PrivateKey); ok {
+			pemBytes, err := marshalEdPrivateKeyPEM(priv)
+			if err != nil {
+				return err
+			}
+			sk.PrivateKey = pemBytes
+		}
+
+		stored = append(stored, sk)
+	}
+
+	return kr.repo.SaveKeyring(ctx, stored)
+}
+
+func marshalEdPrivateKeyPEM(priv ed25519.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal private key: %w", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
@@ -0,0 +1,24 @@
+// Package httperror writes an error returned by a handler to an
+// http.ResponseWriter, using the status code it carries if it has one. The
+// oidc, auth and role handler packages share it instead of each pasting in
+// its own copy.
+package httperror
+
+import "net/http"
+
+// StatusCoder is implemented by lerror's error type. It lets Write pick the
+// right response code without callers needing to know anything else about
+// lerror's internals.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// Write writes err's message to w, using its StatusCoder status if it has
+// one and falling back to 500 otherwise.
+func Write(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if sc, ok := err.(StatusCoder); ok {
+		status = sc.StatusCode()
+	}
+	http.Error(w, err.Error(), status)
+}
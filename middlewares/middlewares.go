@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 
+	"github.com/theleeeo/thor/role"
 	"github.com/theleeeo/thor/sdk"
 )
 
@@ -43,6 +44,24 @@ func ClaimsExtractor(publicKey []byte) Middleware {
 	}
 }
 
+// RequirePermission returns a middleware that 403s any request whose claims
+// don't grant every permission in want. It must run after ClaimsExtractor,
+// since it reads the claims back out of the request context rather than
+// parsing the token itself.
+func RequirePermission(want role.Permission) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := sdk.ClaimFromCtx(r.Context())
+			if claims == nil || !claims.Permissions.Has(want) {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
 // InternalErrorRedacter is a middleware that will redact internal error messages.
 // It will replace the response body with a generic message and an id and log the original message.
 func InternalErrorRedacter() Middleware {
@@ -3,12 +3,15 @@ package oauth
 import (
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/theleeeo/thor/lerror"
 	"github.com/theleeeo/thor/models"
@@ -16,6 +19,11 @@ import (
 	"github.com/theleeeo/thor/user"
 )
 
+// StateTTL is how long a pending login's state/verifier pair is honored.
+// A callback arriving after this window is rejected even if the state and
+// HMAC both check out.
+var StateTTL = 10 * time.Minute
+
 func GenerateState() (string, error) {
 	b := make([]byte, 32) // Adjust size as needed.
 	if _, err := rand.Read(b); err != nil {
@@ -25,12 +33,70 @@ func GenerateState() (string, error) {
 	return state, nil
 }
 
+// generateCodeVerifier returns a PKCE code_verifier per RFC 7636: a
+// cryptographically random string, here 32 raw bytes base64url-encoded.
+func generateCodeVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// codeChallengeS256 derives the PKCE code_challenge for verifier using the
+// S256 transform.
+func codeChallengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// signedState packs the random state together with an HMAC over the
+// session cookie that issued it, so a state value copied out of one
+// browser's redirect can't be replayed in another: verifying it requires
+// presenting the exact cookie it was bound to.
+func signedState(h *OAuthHandler, state string, cookieValue string) string {
+	mac := h.auth.SignState([]byte(cookieValue))
+	return state + "." + base64.RawURLEncoding.EncodeToString(mac)
+}
+
+// writtenCookie reads back the value of a cookie already written to w via
+// Set-Cookie, e.g. right after session.Save, before the response is sent.
+func writtenCookie(w http.ResponseWriter, name string) (string, bool) {
+	resp := http.Response{Header: w.Header()}
+	for _, c := range resp.Cookies() {
+		if c.Name == name {
+			return c.Value, true
+		}
+	}
+	return "", false
+}
+
+// splitSignedState separates the random state from its HMAC suffix.
+func splitSignedState(value string) (state string, mac []byte, ok bool) {
+	i := strings.LastIndex(value, ".")
+	if i < 0 {
+		return "", nil, false
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(value[i+1:])
+	if err != nil {
+		return "", nil, false
+	}
+
+	return value[:i], mac, true
+}
+
 func (h *OAuthHandler) serveLogin(w http.ResponseWriter, r *http.Request, providerID string) error {
 	provider, err := h.getProvider(providerID)
 	if err != nil {
 		return lerror.Wrap(err, "failed to get provider", http.StatusBadRequest)
 	}
 
+	returnTo, err := parseReturnTo(h.allowedReturns, r)
+	if err != nil {
+		return err
+	}
+
 	// The error does not matter as a new session will be created either way.
 	// We want to discard any old sessions anyways
 	session, _ := h.store.New(r, h.sessionName)
@@ -41,26 +107,36 @@ func (h *OAuthHandler) serveLogin(w http.ResponseWriter, r *http.Request, provid
 		return lerror.Wrap(err, "failed to generate a state", http.StatusInternalServerError)
 	}
 
-	session.Values["state"] = state
-	if err := session.Save(r, w); err != nil {
-		return lerror.Wrap(err, "failed to save the state", http.StatusInternalServerError)
-	}
-
-	returnTo, err := parseReturnTo(h.allowedReturns, r)
+	verifier, err := generateCodeVerifier()
 	if err != nil {
-		return err
+		return lerror.Wrap(err, "failed to generate a code verifier", http.StatusInternalServerError)
 	}
 
+	session.Values["state"] = state
+	session.Values["verifier"] = verifier
+	session.Values["state_issued_at"] = time.Now().Unix()
 	if returnTo != "" {
 		session.Values["return"] = returnTo
-		if err := session.Save(r, w); err != nil {
-			return lerror.Wrap(err, "failed to save the return url", http.StatusInternalServerError)
-		}
+	}
+
+	// Save exactly once, with every value already set: the store re-encrypts
+	// the cookie with fresh random nonce on every Save, so a second Save
+	// here would put a second, different Set-Cookie header on the response
+	// - and the value a real browser keeps is the *last* one written, while
+	// writtenCookie below reads back the first. Saving once keeps both in
+	// sync with what the browser will actually send back to serveCallback.
+	if err := session.Save(r, w); err != nil {
+		return lerror.Wrap(err, "failed to save the state", http.StatusInternalServerError)
+	}
+
+	sessionCookieValue, ok := writtenCookie(w, h.sessionName)
+	if !ok {
+		return lerror.New("failed to read back the session cookie", http.StatusInternalServerError)
 	}
 
 	redirectURL := fmt.Sprintf("%s/oauth/callback/%s/%s", h.appUrl.String(), provider.Type(), provider.Name())
 
-	loginURL := provider.BuildLoginUrl(state, redirectURL)
+	loginURL := provider.BuildLoginUrl(signedState(h, state, sessionCookieValue), redirectURL, codeChallengeS256(verifier))
 	http.Redirect(w, r, loginURL, http.StatusFound)
 	return nil
 }
@@ -109,11 +185,25 @@ func (h *OAuthHandler) serveCallback(w http.ResponseWriter, r *http.Request, pro
 		return lerror.New(formError, http.StatusBadRequest)
 	}
 
-	state := r.FormValue("state")
-	if state == "" {
+	rawState := r.FormValue("state")
+	if rawState == "" {
 		return lerror.New("state not found", http.StatusBadRequest)
 	}
 
+	state, mac, ok := splitSignedState(rawState)
+	if !ok {
+		return lerror.New("malformed state", http.StatusBadRequest)
+	}
+
+	sessionCookie, err := r.Cookie(h.sessionName)
+	if err != nil {
+		return lerror.Wrap(err, "failed to read the session cookie", http.StatusBadRequest)
+	}
+
+	if !h.auth.VerifyState([]byte(sessionCookie.Value), mac) {
+		return lerror.New("state does not match this session", http.StatusBadRequest)
+	}
+
 	session, err := h.store.New(r, h.sessionName)
 	if err != nil {
 		return lerror.Wrap(err, "failed to get session", http.StatusBadRequest)
@@ -123,12 +213,19 @@ func (h *OAuthHandler) serveCallback(w http.ResponseWriter, r *http.Request, pro
 		return lerror.New("state mismatch", http.StatusBadRequest)
 	}
 
+	issuedAt, ok := session.Values["state_issued_at"].(int64)
+	if !ok || time.Since(time.Unix(issuedAt, 0)) > StateTTL {
+		return lerror.New("state has expired", http.StatusBadRequest)
+	}
+
+	verifier, _ := session.Values["verifier"].(string)
+
 	code := r.FormValue("code")
 	if code == "" {
 		return lerror.New("code not found", http.StatusBadRequest)
 	}
 
-	u, pr, err := provider.GetUser(code)
+	u, pr, err := provider.GetUser(code, verifier)
 	if err != nil {
 		return lerror.Wrap(err, "failed to get user from provider", http.StatusInternalServerError)
 	}
@@ -138,7 +235,8 @@ func (h *OAuthHandler) serveCallback(w http.ResponseWriter, r *http.Request, pro
 		return err
 	}
 
-	token, err := h.auth.CreateToken(r.Context(), user)
+	clientID := fmt.Sprintf("oauth:%s:%s", provider.Type(), provider.Name())
+	token, refreshToken, err := h.auth.Login(r.Context(), user, clientID, r.UserAgent())
 	if err != nil {
 		return lerror.Wrap(err, "failed to create token", http.StatusInternalServerError)
 	}
@@ -167,6 +265,7 @@ func (h *OAuthHandler) serveCallback(w http.ResponseWriter, r *http.Request, pro
 	}
 
 	http.SetCookie(w, cookie)
+	h.setRefreshCookie(w, refreshToken)
 	w.Header().Set("Location", returnTo)
 	w.WriteHeader(http.StatusFound)
 	return nil
@@ -0,0 +1,84 @@
+package oauth
+
+import (
+	"net/http"
+
+	"github.com/theleeeo/thor/lerror"
+)
+
+// refreshCookieName is the name of the cookie carrying the opaque refresh
+// token, scoped separately from the access-token cookie so refresh tokens
+// aren't readable by application code that only expects a JWT.
+func (h *OAuthHandler) refreshCookieName() string {
+	return h.cookieName + "_refresh"
+}
+
+func (h *OAuthHandler) setRefreshCookie(w http.ResponseWriter, value string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     h.refreshCookieName(),
+		Value:    value,
+		Path:     "/oauth",
+		SameSite: http.SameSiteLaxMode,
+		HttpOnly: true,
+		Secure:   !(h.appUrl.Scheme == "http"),
+	})
+}
+
+func (h *OAuthHandler) clearAuthCookies(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Name: h.cookieName, Value: "", Path: "/", MaxAge: -1})
+	http.SetCookie(w, &http.Cookie{Name: h.refreshCookieName(), Value: "", Path: "/oauth", MaxAge: -1})
+}
+
+// ServeRefresh handles POST /oauth/refresh. It exchanges the refresh token
+// cookie for a new access token, rotating the refresh token in the process.
+func (h *OAuthHandler) ServeRefresh(w http.ResponseWriter, r *http.Request) {
+	if err := h.serveRefresh(w, r); err != nil {
+		writeError(w, err)
+	}
+}
+
+func (h *OAuthHandler) serveRefresh(w http.ResponseWriter, r *http.Request) error {
+	cookie, err := r.Cookie(h.refreshCookieName())
+	if err != nil {
+		return lerror.New("missing refresh token", http.StatusUnauthorized)
+	}
+
+	accessToken, refreshToken, err := h.auth.Refresh(r.Context(), cookie.Value, r.UserAgent())
+	if err != nil {
+		h.clearAuthCookies(w)
+		return lerror.Wrap(err, "failed to refresh token", http.StatusUnauthorized)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     h.cookieName,
+		Value:    accessToken,
+		Path:     "/",
+		SameSite: http.SameSiteLaxMode,
+		HttpOnly: true,
+		Secure:   !(h.appUrl.Scheme == "http"),
+	})
+	h.setRefreshCookie(w, refreshToken)
+
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// ServeLogout handles POST /oauth/logout. It revokes the presented refresh
+// token's entire session family and clears both auth cookies.
+func (h *OAuthHandler) ServeLogout(w http.ResponseWriter, r *http.Request) {
+	if err := h.serveLogout(w, r); err != nil {
+		writeError(w, err)
+	}
+}
+
+func (h *OAuthHandler) serveLogout(w http.ResponseWriter, r *http.Request) error {
+	if cookie, err := r.Cookie(h.refreshCookieName()); err == nil {
+		if err := h.auth.Logout(r.Context(), cookie.Value); err != nil {
+			return lerror.Wrap(err, "failed to revoke refresh token", http.StatusInternalServerError)
+		}
+	}
+
+	h.clearAuthCookies(w)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
@@ -0,0 +1,296 @@
+package oidc
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/theleeeo/thor/authorizer"
+	"github.com/theleeeo/thor/httperror"
+	"github.com/theleeeo/thor/lerror"
+)
+
+type discoveryDocument struct {
+	Issuer                           string   `json:"issuer"`
+	AuthorizationEndpoint            string   `json:"authorization_endpoint"`
+	TokenEndpoint                    string   `json:"token_endpoint"`
+	UserinfoEndpoint                 string   `json:"userinfo_endpoint"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	ResponseTypesSupported           []string `json:"response_types_supported"`
+	SubjectTypesSupported            []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                  []string `json:"scopes_supported"`
+	CodeChallengeMethodsSupported    []string `json:"code_challenge_methods_supported"`
+}
+
+// ServeDiscovery handles GET /.well-known/openid-configuration.
+func (h *Handler) ServeDiscovery(w http.ResponseWriter, r *http.Request) {
+	issuer := h.auth.Issuer()
+
+	doc := discoveryDocument{
+		Issuer:                           issuer,
+		AuthorizationEndpoint:            issuer + "/authorize",
+		TokenEndpoint:                    issuer + "/token",
+		UserinfoEndpoint:                 issuer + "/userinfo",
+		JWKSURI:                          issuer + "/.well-known/jwks.json",
+		ResponseTypesSupported:           []string{"code"},
+		SubjectTypesSupported:            []string{"public"},
+		IDTokenSigningAlgValuesSupported: []string{"EdDSA"},
+		ScopesSupported:                  []string{"openid", "profile", "email"},
+		CodeChallengeMethodsSupported:    []string{"S256"},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(doc)
+}
+
+// ServeJWKS handles GET /.well-known/jwks.json.
+func (h *Handler) ServeJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Keys []authorizer.JWK `json:"keys"`
+	}{Keys: h.auth.PublicKeys()})
+}
+
+// ServeAuthorize handles GET /authorize. It expects the end user to already
+// carry Thor's own session cookie (cookieName); if they don't, it bounces
+// them to the regular login UI with a return link back to this request so
+// the flow can resume once they're signed in.
+func (h *Handler) ServeAuthorize(cookieName string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := h.serveAuthorize(cookieName, w, r); err != nil {
+			httperror.Write(w, err)
+		}
+	}
+}
+
+func (h *Handler) serveAuthorize(cookieName string, w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query()
+
+	clientID := q.Get("client_id")
+	redirectURI := q.Get("redirect_uri")
+
+	client, err := h.clients.GetOIDCClient(r.Context(), clientID)
+	if err != nil {
+		return lerror.Wrap(err, "unknown client", http.StatusBadRequest)
+	}
+
+	if !containsString(client.RedirectURIs, redirectURI) {
+		return lerror.New("redirect_uri is not registered for this client", http.StatusBadRequest)
+	}
+
+	if q.Get("response_type") != "code" {
+		return redirectWithError(w, r, redirectURI, "unsupported_response_type")
+	}
+
+	if !scopesAllowed(client.AllowedScopes, q.Get("scope")) {
+		return redirectWithError(w, r, redirectURI, "invalid_scope")
+	}
+
+	// A client with no secret is public (e.g. a SPA or native app) and has
+	// no other way to prove it's the one that started this flow, so PKCE
+	// is mandatory for it; a confidential client authenticates itself with
+	// client_secret at the token endpoint instead.
+	if client.Secret == "" && q.Get("code_challenge") == "" {
+		return redirectWithError(w, r, redirectURI, "invalid_request")
+	}
+
+	cookie, err := r.Cookie(cookieName)
+	if err != nil {
+		redirectToLogin(w, r)
+		return nil
+	}
+
+	claims, err := h.auth.Decode(cookie.Value)
+	if err != nil {
+		redirectToLogin(w, r)
+		return nil
+	}
+
+	code, err := generateCode()
+	if err != nil {
+		return lerror.Wrap(err, "failed to generate authorization code", http.StatusInternalServerError)
+	}
+
+	h.putCode(code, &authCode{
+		ClientID:            clientID,
+		RedirectURI:         redirectURI,
+		Scope:               q.Get("scope"),
+		Nonce:               q.Get("nonce"),
+		CodeChallenge:       q.Get("code_challenge"),
+		CodeChallengeMethod: q.Get("code_challenge_method"),
+		UserID:              claims.UserID,
+		ExpiresAt:           time.Now().Add(authCodeTTL),
+	})
+
+	redirectTo, err := url.Parse(redirectURI)
+	if err != nil {
+		return lerror.Wrap(err, "invalid redirect_uri", http.StatusBadRequest)
+	}
+
+	values := redirectTo.Query()
+	values.Set("code", code)
+	if state := q.Get("state"); state != "" {
+		values.Set("state", state)
+	}
+	redirectTo.RawQuery = values.Encode()
+
+	http.Redirect(w, r, redirectTo.String(), http.StatusFound)
+	return nil
+}
+
+// scopesAllowed reports whether every space-separated scope in requested is
+// in allowed. An empty allowed list means the client has no configured
+// restriction, so anything is accepted.
+func scopesAllowed(allowed []string, requested string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	for _, s := range strings.Fields(requested) {
+		if !containsString(allowed, s) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func redirectToLogin(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, "/login?return="+url.QueryEscape(r.URL.String()), http.StatusFound)
+}
+
+func redirectWithError(w http.ResponseWriter, r *http.Request, redirectURI, errCode string) error {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return lerror.Wrap(err, "invalid redirect_uri", http.StatusBadRequest)
+	}
+
+	values := u.Query()
+	values.Set("error", errCode)
+	u.RawQuery = values.Encode()
+
+	http.Redirect(w, r, u.String(), http.StatusFound)
+	return nil
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// ServeToken handles POST /token for the authorization_code grant.
+func (h *Handler) ServeToken(w http.ResponseWriter, r *http.Request) {
+	if err := h.serveToken(w, r); err != nil {
+		httperror.Write(w, err)
+	}
+}
+
+func (h *Handler) serveToken(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return lerror.Wrap(err, "failed to parse form", http.StatusBadRequest)
+	}
+
+	if r.FormValue("grant_type") != "authorization_code" {
+		return lerror.New("unsupported grant_type", http.StatusBadRequest)
+	}
+
+	ac, ok := h.takeCode(r.FormValue("code"))
+	if !ok {
+		return lerror.New("invalid or expired authorization code", http.StatusBadRequest)
+	}
+
+	if ac.ClientID != r.FormValue("client_id") {
+		return lerror.New("client_id does not match the authorization code", http.StatusBadRequest)
+	}
+
+	if ac.RedirectURI != r.FormValue("redirect_uri") {
+		return lerror.New("redirect_uri does not match the authorization code", http.StatusBadRequest)
+	}
+
+	client, err := h.clients.GetOIDCClient(r.Context(), ac.ClientID)
+	if err != nil {
+		return lerror.Wrap(err, "unknown client", http.StatusBadRequest)
+	}
+
+	// A confidential client (one with a secret) must present it here; a
+	// public client has none to present and was instead required to use
+	// PKCE back at /authorize.
+	if client.Secret != "" && subtle.ConstantTimeCompare([]byte(r.FormValue("client_secret")), []byte(client.Secret)) != 1 {
+		return lerror.New("invalid client_secret", http.StatusUnauthorized)
+	}
+
+	if !verifyPKCE(r.FormValue("code_verifier"), ac.CodeChallenge, ac.CodeChallengeMethod) {
+		return lerror.New("code_verifier does not match the code_challenge", http.StatusBadRequest)
+	}
+
+	u, err := h.users.GetByID(r.Context(), ac.UserID)
+	if err != nil {
+		return lerror.Wrap(err, "failed to load user", http.StatusInternalServerError)
+	}
+
+	perms, err := h.roles.EffectivePermissions(r.Context(), u.ID, u.Role)
+	if err != nil {
+		return lerror.Wrap(err, "failed to resolve permissions", http.StatusInternalServerError)
+	}
+
+	accessToken, err := h.auth.CreateToken(&u.User, perms)
+	if err != nil {
+		return lerror.Wrap(err, "failed to create access token", http.StatusInternalServerError)
+	}
+
+	idToken := ""
+	if strings.Contains(ac.Scope, "openid") {
+		idToken, err = h.auth.CreateIDToken(&u.User, ac.ClientID, ac.Nonce)
+		if err != nil {
+			return lerror.Wrap(err, "failed to create id token", http.StatusInternalServerError)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(tokenResponse{
+		AccessToken: accessToken,
+		IDToken:     idToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(h.auth.ValidDuration().Seconds()),
+	})
+}
+
+// ServeUserinfo handles GET /userinfo.
+func (h *Handler) ServeUserinfo(w http.ResponseWriter, r *http.Request) {
+	if err := h.serveUserinfo(w, r); err != nil {
+		httperror.Write(w, err)
+	}
+}
+
+func (h *Handler) serveUserinfo(w http.ResponseWriter, r *http.Request) error {
+	authHeader := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if !strings.HasPrefix(authHeader, prefix) {
+		return lerror.New("missing bearer token", http.StatusUnauthorized)
+	}
+
+	claims, err := h.auth.Decode(strings.TrimPrefix(authHeader, prefix))
+	if err != nil {
+		return lerror.Wrap(err, "invalid access token", http.StatusUnauthorized)
+	}
+
+	u, err := h.users.GetByID(r.Context(), claims.UserID)
+	if err != nil {
+		return lerror.Wrap(err, "failed to load user", http.StatusInternalServerError)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(struct {
+		Sub   string `json:"sub"`
+		Email string `json:"email"`
+	}{
+		Sub:   u.User.ID,
+		Email: u.User.Email,
+	})
+}
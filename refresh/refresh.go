@@ -0,0 +1,206 @@
+// Package refresh implements Thor's refresh-token subsystem: long-lived,
+// one-time-use opaque tokens that let a client obtain new access JWTs
+// without sending the user back through the login flow, while still being
+// revocable server-side before they expire.
+package refresh
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+// DefaultTTL is how long a freshly issued refresh token is valid for.
+const DefaultTTL = 30 * 24 * time.Hour
+
+// ErrInvalidToken is returned when a presented refresh token doesn't match
+// any stored token, or when Rotate is given an expired one. A revoked token
+// presented to Rotate yields ErrReuseDetected instead.
+var ErrInvalidToken = errors.New("invalid or expired refresh token")
+
+// ErrReuseDetected is returned by Rotate when a refresh token that has
+// already been rotated away is presented again. A legitimate client always
+// moves on to the token it was last issued, so this is treated as a strong
+// signal the token was stolen, and the whole family is revoked in response.
+var ErrReuseDetected = errors.New("refresh token reuse detected")
+
+// Token is a stored refresh token record. Raw only holds a value right
+// after Issue or Rotate mint it; it is never persisted and ListSessions
+// never populates it.
+type Token struct {
+	ID        string
+	FamilyID  string
+	UserID    string
+	ClientID  string
+	UserAgent string
+	Hash      []byte
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+
+	Raw string
+}
+
+func (t *Token) revoked() bool {
+	return t.RevokedAt != nil
+}
+
+func (t *Token) expired() bool {
+	return time.Now().After(t.ExpiresAt)
+}
+
+// Repo is the subset of repo.Repo the refresh package needs to persist
+// tokens.
+type Repo interface {
+	SaveRefreshToken(ctx context.Context, t Token) error
+	GetRefreshTokenByHash(ctx context.Context, hash []byte) (*Token, error)
+	// RevokeRefreshToken atomically revokes id unless it is already revoked,
+	// reporting whether this call was the one that did it. Rotate relies on
+	// that atomicity to tell a legitimate rotation from concurrent reuse of
+	// the same token.
+	RevokeRefreshToken(ctx context.Context, id string) (revoked bool, err error)
+	RevokeFamily(ctx context.Context, familyID string) error
+	ListRefreshTokens(ctx context.Context, userID string) ([]Token, error)
+}
+
+// Service issues, rotates and revokes refresh tokens.
+type Service struct {
+	repo Repo
+	ttl  time.Duration
+}
+
+// New returns a Service backed by repo. ttl is how long a freshly issued
+// token stays valid; a non-positive ttl falls back to DefaultTTL.
+func New(repo Repo, ttl time.Duration) *Service {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Service{repo: repo, ttl: ttl}
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashRaw(raw string) []byte {
+	sum := sha256.Sum256([]byte(raw))
+	return sum[:]
+}
+
+// Issue mints a brand new refresh token, starting a new rotation family of
+// its own.
+func (s *Service) Issue(ctx context.Context, userID, clientID, userAgent string) (*Token, error) {
+	return s.issue(ctx, userID, clientID, userAgent, "")
+}
+
+// issue mints and persists a new token. An empty familyID starts a new
+// family; otherwise the new token joins familyID, so Rotate can tell a
+// stolen predecessor from the legitimate chain.
+func (s *Service) issue(ctx context.Context, userID, clientID, userAgent, familyID string) (*Token, error) {
+	id, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+
+	if familyID == "" {
+		familyID = id
+	}
+
+	now := time.Now()
+	t := Token{
+		ID:        id,
+		FamilyID:  familyID,
+		UserID:    userID,
+		ClientID:  clientID,
+		UserAgent: userAgent,
+		Hash:      hashRaw(raw),
+		IssuedAt:  now,
+		ExpiresAt: now.Add(s.ttl),
+		Raw:       raw,
+	}
+
+	stored := t
+	stored.Raw = ""
+	if err := s.repo.SaveRefreshToken(ctx, stored); err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// Rotate exchanges raw for a new refresh token in the same family and
+// revokes raw so it can't be used again. userAgent is recorded against the
+// new token; the client that owns the family is carried over from raw's
+// record rather than trusted from the caller.
+//
+// Reuse detection goes through RevokeRefreshToken's atomic revoke-if-active
+// rather than checking t.revoked() and then revoking as two separate steps:
+// two concurrent Rotate calls presenting the same still-valid token would
+// otherwise both pass the check before either revoked it, letting the thief
+// and the legitimate client both rotate successfully.
+func (s *Service) Rotate(ctx context.Context, raw, userAgent string) (*Token, error) {
+	t, err := s.repo.GetRefreshTokenByHash(ctx, hashRaw(raw))
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if t.expired() {
+		return nil, ErrInvalidToken
+	}
+
+	revoked, err := s.repo.RevokeRefreshToken(ctx, t.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !revoked {
+		if err := s.repo.RevokeFamily(ctx, t.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrReuseDetected
+	}
+
+	return s.issue(ctx, t.UserID, t.ClientID, userAgent, t.FamilyID)
+}
+
+// Revoke invalidates raw's entire rotation family, e.g. on logout.
+func (s *Service) Revoke(ctx context.Context, raw string) error {
+	t, err := s.repo.GetRefreshTokenByHash(ctx, hashRaw(raw))
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	return s.repo.RevokeFamily(ctx, t.FamilyID)
+}
+
+// ListSessions returns userID's refresh tokens that are neither revoked nor
+// expired, i.e. their currently active sessions.
+func (s *Service) ListSessions(ctx context.Context, userID string) ([]Token, error) {
+	all, err := s.repo.ListRefreshTokens(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	active := make([]Token, 0, len(all))
+	for _, t := range all {
+		if t.revoked() || t.expired() {
+			continue
+		}
+		t.Hash = nil
+		active = append(active, t)
+	}
+
+	return active, nil
+}
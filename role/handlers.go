@@ -0,0 +1,80 @@
+package role
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/theleeeo/thor/httperror"
+	"github.com/theleeeo/thor/lerror"
+)
+
+// Handler exposes the administrative HTTP endpoints for listing roles and
+// assigning/revoking them. Routes are expected to be guarded by
+// middlewares.RequirePermission(role.PermWriteRoles) (or PermReadRoles for
+// the list endpoint).
+type Handler struct {
+	roles *Service
+}
+
+func NewHandler(roles *Service) *Handler {
+	return &Handler{roles: roles}
+}
+
+// ServeList handles GET /roles.
+func (h *Handler) ServeList(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.roles.ListRoles(r.Context())
+	if err != nil {
+		httperror.Write(w, lerror.Wrap(err, "failed to list roles", http.StatusInternalServerError))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(roles)
+}
+
+type assignmentRequest struct {
+	UserID string `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+// ServeAssign handles POST /roles/assign.
+func (h *Handler) ServeAssign(w http.ResponseWriter, r *http.Request) {
+	if err := h.serveAssign(w, r); err != nil {
+		httperror.Write(w, err)
+	}
+}
+
+func (h *Handler) serveAssign(w http.ResponseWriter, r *http.Request) error {
+	var req assignmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return lerror.Wrap(err, "failed to parse request body", http.StatusBadRequest)
+	}
+
+	if err := h.roles.AssignRole(r.Context(), req.UserID, req.Role); err != nil {
+		return lerror.Wrap(err, "failed to assign role", http.StatusInternalServerError)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// ServeRevoke handles POST /roles/revoke.
+func (h *Handler) ServeRevoke(w http.ResponseWriter, r *http.Request) {
+	if err := h.serveRevoke(w, r); err != nil {
+		httperror.Write(w, err)
+	}
+}
+
+func (h *Handler) serveRevoke(w http.ResponseWriter, r *http.Request) error {
+	var req assignmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return lerror.Wrap(err, "failed to parse request body", http.StatusBadRequest)
+	}
+
+	if err := h.roles.RevokeRole(r.Context(), req.UserID, req.Role); err != nil {
+		return lerror.Wrap(err, "failed to revoke role", http.StatusInternalServerError)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
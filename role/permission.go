@@ -0,0 +1,23 @@
+// Package role provides Thor's RBAC subsystem: named roles built from a
+// bitmask of permissions, repo-backed assignments per user, and the
+// middleware that enforces them.
+package role
+
+// Permission is a bitmask of capabilities a token's holder is allowed to
+// exercise. Bits are additive, so a caller can be granted e.g.
+// PermReadUsers|PermWriteUsers without needing a dedicated role to match
+// that exact combination.
+type Permission uint64
+
+const (
+	PermReadUsers Permission = 1 << iota
+	PermWriteUsers
+	PermReadRoles
+	PermWriteRoles
+	PermAdmin
+)
+
+// Has reports whether p grants every permission in want.
+func (p Permission) Has(want Permission) bool {
+	return p&want == want
+}
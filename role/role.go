@@ -0,0 +1,38 @@
+package role
+
+// Role is a named, reusable bundle of permissions that can be assigned to a
+// user.
+type Role struct {
+	Name        string
+	Permissions Permission
+}
+
+// RoleUser and RoleAdmin are Thor's built-in roles, named after the legacy
+// "user"/"admin" strings still stored on models.User.Role; DefaultRoleFor
+// maps those strings onto these so users without any role assignment keep
+// their effective permissions unchanged.
+var (
+	RoleUser = Role{
+		Name:        "user",
+		Permissions: PermReadUsers,
+	}
+	RoleAdmin = Role{
+		Name:        "admin",
+		Permissions: PermReadUsers | PermWriteUsers | PermReadRoles | PermWriteRoles | PermAdmin,
+	}
+)
+
+var builtinRoles = map[string]Role{
+	RoleUser.Name:  RoleUser,
+	RoleAdmin.Name: RoleAdmin,
+}
+
+// DefaultRoleFor maps a legacy models.User.Role string to its replacement
+// Role definition. Unknown values are treated as RoleUser, matching how an
+// empty/unrecognized role has always behaved.
+func DefaultRoleFor(legacyRole string) Role {
+	if r, ok := builtinRoles[legacyRole]; ok {
+		return r
+	}
+	return RoleUser
+}
@@ -0,0 +1,56 @@
+package role
+
+import "context"
+
+// Repo is the subset of repo.Repo that the role subsystem needs to persist
+// role definitions and per-user assignments.
+type Repo interface {
+	ListRoles(ctx context.Context) ([]Role, error)
+	GetUserRoles(ctx context.Context, userID string) ([]Role, error)
+	AssignRole(ctx context.Context, userID, roleName string) error
+	RevokeRole(ctx context.Context, userID, roleName string) error
+}
+
+// Service resolves a user's effective permissions from their assigned
+// roles and exposes role administration.
+type Service struct {
+	repo Repo
+}
+
+func NewService(repo Repo) *Service {
+	return &Service{repo: repo}
+}
+
+// EffectivePermissions returns the union of every permission granted by
+// userID's assigned roles. If the user has no roles assigned - e.g. they
+// predate this subsystem, or were created without an assignment - it falls
+// back to the role that legacyRole (models.User.Role) maps to.
+func (s *Service) EffectivePermissions(ctx context.Context, userID, legacyRole string) (Permission, error) {
+	roles, err := s.repo.GetUserRoles(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(roles) == 0 {
+		return DefaultRoleFor(legacyRole).Permissions, nil
+	}
+
+	var perms Permission
+	for _, r := range roles {
+		perms |= r.Permissions
+	}
+
+	return perms, nil
+}
+
+func (s *Service) ListRoles(ctx context.Context) ([]Role, error) {
+	return s.repo.ListRoles(ctx)
+}
+
+func (s *Service) AssignRole(ctx context.Context, userID, roleName string) error {
+	return s.repo.AssignRole(ctx, userID, roleName)
+}
+
+func (s *Service) RevokeRole(ctx context.Context, userID, roleName string) error {
+	return s.repo.RevokeRole(ctx, userID, roleName)
+}
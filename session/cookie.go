@@ -0,0 +1,162 @@
+package session
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// CookieStore keeps the whole session inside the cookie itself: the Values
+// map is gob-encoded, AES-GCM encrypted and HMAC-authenticated, so nothing
+// is stored server-side and any replica can decode a cookie on its own.
+type CookieStore struct {
+	encKey  []byte // AES-256 key
+	hmacKey []byte
+	maxAge  int
+}
+
+// NewCookieStore derives encryption and authentication keys from secret, so
+// a single config value is enough to configure the store. maxAge is applied
+// to every cookie Save writes; pass 0 for a session cookie that expires
+// when the browser closes.
+func NewCookieStore(secret []byte, maxAge int) *CookieStore {
+	return &CookieStore{
+		encKey:  deriveKey(secret, "thor-session-enc"),
+		hmacKey: deriveKey(secret, "thor-session-mac"),
+		maxAge:  maxAge,
+	}
+}
+
+func deriveKey(secret []byte, label string) []byte {
+	sum := sha256.Sum256(append([]byte(label), secret...))
+	return sum[:]
+}
+
+func (st *CookieStore) New(r *http.Request, name string) (*Session, error) {
+	s := newSession(st, name)
+
+	c, err := r.Cookie(name)
+	if err != nil {
+		return s, nil
+	}
+
+	if err := st.decode(c.Value, s); err != nil {
+		// A tampered or stale cookie is treated as no session at all,
+		// matching how a missing cookie is handled above.
+		return s, nil
+	}
+
+	s.IsNew = false
+	return s, nil
+}
+
+func (st *CookieStore) Get(r *http.Request, name string) (*Session, error) {
+	return st.New(r, name)
+}
+
+func (st *CookieStore) Save(r *http.Request, w http.ResponseWriter, s *Session) error {
+	encoded, err := st.encode(s)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.name,
+		Value:    encoded,
+		Path:     s.Options.Path,
+		MaxAge:   st.maxAge,
+		Secure:   s.Options.Secure,
+		HttpOnly: s.Options.HttpOnly,
+		SameSite: s.Options.SameSite,
+	})
+	return nil
+}
+
+func (st *CookieStore) Delete(r *http.Request, w http.ResponseWriter, s *Session) error {
+	http.SetCookie(w, &http.Cookie{
+		Name:   s.name,
+		Value:  "",
+		Path:   s.Options.Path,
+		MaxAge: -1,
+	})
+	return nil
+}
+
+func (st *CookieStore) encode(s *Session) (string, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(s.Values); err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher(st.encKey)
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	ciphertext := gcm.Seal(nonce, nonce, buf.Bytes(), nil)
+
+	mac := hmac.New(sha256.New, st.hmacKey)
+	mac.Write(ciphertext)
+	signed := mac.Sum(ciphertext)
+
+	return base64.RawURLEncoding.EncodeToString(signed), nil
+}
+
+func (st *CookieStore) decode(value string, s *Session) error {
+	signed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return err
+	}
+
+	if len(signed) < sha256.Size {
+		return errors.New("session cookie is too short")
+	}
+
+	ciphertext, mac := signed[:len(signed)-sha256.Size], signed[len(signed)-sha256.Size:]
+
+	want := hmac.New(sha256.New, st.hmacKey)
+	want.Write(ciphertext)
+	if !hmac.Equal(mac, want.Sum(nil)) {
+		return errors.New("session cookie failed authentication")
+	}
+
+	block, err := aes.NewCipher(st.encKey)
+	if err != nil {
+		return err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return errors.New("session cookie is too short")
+	}
+
+	nonce, box := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, box, nil)
+	if err != nil {
+		return err
+	}
+
+	return gob.NewDecoder(bytes.NewReader(plaintext)).Decode(&s.Values)
+}
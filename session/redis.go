@@ -0,0 +1,133 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/gob"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// RedisClient is the subset of a Redis client the session package needs.
+// Keeping it this narrow lets callers pass any client (go-redis,
+// redigo, ...) without Thor depending on a specific one.
+type RedisClient interface {
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	Del(ctx context.Context, key string) error
+}
+
+// RedisStore keeps only a random session ID in the cookie; the Values map
+// is gob-encoded and stored server-side in Redis under that ID, so a
+// session survives independently of which replica handles a request.
+type RedisStore struct {
+	client RedisClient
+	ttl    time.Duration
+	maxAge int
+}
+
+// NewRedisStore returns a Store backed by client. ttl governs how long
+// Redis retains a session; maxAge is applied to the SID cookie itself.
+func NewRedisStore(client RedisClient, ttl time.Duration, maxAge int) *RedisStore {
+	return &RedisStore{client: client, ttl: ttl, maxAge: maxAge}
+}
+
+func generateSID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (st *RedisStore) New(r *http.Request, name string) (*Session, error) {
+	s := newSession(st, name)
+
+	c, err := r.Cookie(name)
+	if err != nil {
+		return s, nil
+	}
+
+	raw, err := st.client.Get(r.Context(), redisKey(name, c.Value))
+	if err != nil {
+		// No record for this SID, e.g. expired or never existed: treat it
+		// the same as no cookie at all.
+		return s, nil
+	}
+
+	if err := decodeValues(raw, &s.Values); err != nil {
+		return s, nil
+	}
+
+	s.ID = c.Value
+	s.IsNew = false
+	return s, nil
+}
+
+func (st *RedisStore) Get(r *http.Request, name string) (*Session, error) {
+	return st.New(r, name)
+}
+
+func (st *RedisStore) Save(r *http.Request, w http.ResponseWriter, s *Session) error {
+	if s.ID == "" {
+		sid, err := generateSID()
+		if err != nil {
+			return fmt.Errorf("failed to generate session id: %w", err)
+		}
+		s.ID = sid
+	}
+
+	encoded, err := encodeValues(s.Values)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+
+	if err := st.client.Set(r.Context(), redisKey(s.name, s.ID), encoded, st.ttl); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.name,
+		Value:    s.ID,
+		Path:     s.Options.Path,
+		MaxAge:   st.maxAge,
+		Secure:   s.Options.Secure,
+		HttpOnly: s.Options.HttpOnly,
+		SameSite: s.Options.SameSite,
+	})
+	return nil
+}
+
+func (st *RedisStore) Delete(r *http.Request, w http.ResponseWriter, s *Session) error {
+	if s.ID != "" {
+		if err := st.client.Del(r.Context(), redisKey(s.name, s.ID)); err != nil {
+			return err
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: s.name, Value: "", Path: s.Options.Path, MaxAge: -1})
+	return nil
+}
+
+func redisKey(name, sid string) string {
+	return "thor:session:" + name + ":" + sid
+}
+
+func encodeValues(values map[interface{}]interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(values); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func decodeValues(raw string, values *map[interface{}]interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return err
+	}
+	return gob.NewDecoder(bytes.NewReader(b)).Decode(values)
+}
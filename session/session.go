@@ -0,0 +1,68 @@
+// Package session defines Thor's pluggable session-store abstraction used
+// by the OAuth client flow to carry short-lived state (the pending state
+// value, PKCE verifier and return URL) between the login redirect and the
+// provider callback. Swapping the Store implementation lets the OAuth
+// handler scale across replicas without any caller-visible change.
+package session
+
+import (
+	"net/http"
+)
+
+// Options mirrors the subset of cookie attributes a Store needs to control
+// when it persists a Session.
+type Options struct {
+	Path     string
+	MaxAge   int
+	Secure   bool
+	HttpOnly bool
+	SameSite http.SameSite
+}
+
+// Session is a bag of values scoped to one browser, plus the bookkeeping a
+// Store needs to save or delete it again.
+type Session struct {
+	ID      string
+	Values  map[interface{}]interface{}
+	Options *Options
+	IsNew   bool
+
+	store Store
+	name  string
+}
+
+// newSession returns an empty session backed by store, ready for a handler
+// to populate Values before calling Save.
+func newSession(store Store, name string) *Session {
+	return &Session{
+		Values:  make(map[interface{}]interface{}),
+		Options: &Options{Path: "/", HttpOnly: true},
+		IsNew:   true,
+		store:   store,
+		name:    name,
+	}
+}
+
+// Name returns the session's cookie name.
+func (s *Session) Name() string {
+	return s.name
+}
+
+// Save persists s via the Store that created it.
+func (s *Session) Save(r *http.Request, w http.ResponseWriter) error {
+	return s.store.Save(r, w, s)
+}
+
+// Store is implemented by every session backend Thor supports: an
+// encrypted-cookie store, a Redis-backed store and a SQL store (see
+// cookie.go, redis.go and sql.go). New always returns a usable session,
+// decoding one out of the request if present; Get is an alias for New kept
+// for parity with how callers already used the prior gorilla-sessions-based
+// store. Save persists s and writes its cookie; Delete removes it and
+// expires the cookie.
+type Store interface {
+	New(r *http.Request, name string) (*Session, error)
+	Get(r *http.Request, name string) (*Session, error)
+	Save(r *http.Request, w http.ResponseWriter, s *Session) error
+	Delete(r *http.Request, w http.ResponseWriter, s *Session) error
+}
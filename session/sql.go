@@ -0,0 +1,97 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Repo is the subset of repo.Repo the SQL session store needs.
+type Repo interface {
+	SaveSession(ctx context.Context, name, id, data string, expiresAt time.Time) error
+	GetSession(ctx context.Context, name, id string) (string, error)
+	DeleteSession(ctx context.Context, name, id string) error
+}
+
+// SQLStore keeps only a random session ID in the cookie, same as
+// RedisStore, but persists the Values map through repo.Repo instead of a
+// cache - trading Redis's speed for not needing an extra moving part.
+type SQLStore struct {
+	repo   Repo
+	ttl    time.Duration
+	maxAge int
+}
+
+// NewSQLStore returns a Store backed by repo. ttl governs how long a
+// session row remains valid; maxAge is applied to the SID cookie itself.
+func NewSQLStore(repo Repo, ttl time.Duration, maxAge int) *SQLStore {
+	return &SQLStore{repo: repo, ttl: ttl, maxAge: maxAge}
+}
+
+func (st *SQLStore) New(r *http.Request, name string) (*Session, error) {
+	s := newSession(st, name)
+
+	c, err := r.Cookie(name)
+	if err != nil {
+		return s, nil
+	}
+
+	raw, err := st.repo.GetSession(r.Context(), name, c.Value)
+	if err != nil {
+		return s, nil
+	}
+
+	if err := decodeValues(raw, &s.Values); err != nil {
+		return s, nil
+	}
+
+	s.ID = c.Value
+	s.IsNew = false
+	return s, nil
+}
+
+func (st *SQLStore) Get(r *http.Request, name string) (*Session, error) {
+	return st.New(r, name)
+}
+
+func (st *SQLStore) Save(r *http.Request, w http.ResponseWriter, s *Session) error {
+	if s.ID == "" {
+		sid, err := generateSID()
+		if err != nil {
+			return fmt.Errorf("failed to generate session id: %w", err)
+		}
+		s.ID = sid
+	}
+
+	encoded, err := encodeValues(s.Values)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+
+	if err := st.repo.SaveSession(r.Context(), s.name, s.ID, encoded, time.Now().Add(st.ttl)); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.name,
+		Value:    s.ID,
+		Path:     s.Options.Path,
+		MaxAge:   st.maxAge,
+		Secure:   s.Options.Secure,
+		HttpOnly: s.Options.HttpOnly,
+		SameSite: s.Options.SameSite,
+	})
+	return nil
+}
+
+func (st *SQLStore) Delete(r *http.Request, w http.ResponseWriter, s *Session) error {
+	if s.ID != "" {
+		if err := st.repo.DeleteSession(r.Context(), s.name, s.ID); err != nil {
+			return err
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: s.name, Value: "", Path: s.Options.Path, MaxAge: -1})
+	return nil
+}
@@ -53,3 +53,20 @@ func (s *Service) GetByProviderID(ctx context.Context, providerID string) (*User
 		User: *u,
 	}, nil
 }
+
+// CreateWithProvider creates a user the same way Create does, and attaches
+// provider to it right away. This is used by login paths that know up
+// front which provider the account should be linked to, such as password
+// registration, instead of relying on a later lookup-by-email to link it.
+func (s *Service) CreateWithProvider(ctx context.Context, user *models.User, provider models.UserProvider) (*User, error) {
+	u, err := s.Create(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.AddProvider(ctx, provider); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}